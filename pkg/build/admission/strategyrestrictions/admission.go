@@ -3,7 +3,11 @@ package strategyrestrictions
 import (
 	"fmt"
 	"io"
+	"os"
 	"strings"
+	"sync/atomic"
+
+	"github.com/golang/glog"
 
 	"github.com/openshift/origin/pkg/build/buildscheme"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -26,25 +30,84 @@ import (
 func Register(plugins *admission.Plugins) {
 	plugins.Register("BuildByStrategy",
 		func(config io.Reader) (admission.Interface, error) {
-			return NewBuildByStrategy(), nil
+			return newConfiguredBuildByStrategy(config)
 		})
+	registerExecRestriction(plugins)
+}
+
+// newConfiguredBuildByStrategy decodes config and builds a *buildByStrategy from
+// it, watching it for changes if it came from a file. Both BuildByStrategy and
+// BuildByStrategyExecRestriction are backed by this same construction path, so
+// each applies the image allowlist, DryRun mode and authorizer backend of the
+// config it is handed; each plugin decodes only its own config reader.
+func newConfiguredBuildByStrategy(config io.Reader) (*buildByStrategy, error) {
+	pluginConfig, err := readConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	b, err := NewBuildByStrategy(pluginConfig)
+	if err != nil {
+		return nil, err
+	}
+	bbs := b.(*buildByStrategy)
+	// If the config came from a file, watch it for changes so that ImagePolicy
+	// and DryRun can be adjusted without restarting the API server, the same way
+	// a cluster admin stages an RBAC policy.
+	if f, ok := config.(*os.File); ok {
+		startConfigWatcher(bbs, f.Name())
+	}
+	return bbs, nil
 }
 
 type buildByStrategy struct {
 	*admission.Handler
 	sarClient   authorizationclient.SubjectAccessReviewInterface
 	buildClient buildclient.Interface
+	authorizer  StrategyAuthorizer
+	config      atomic.Value // holds *Config
 }
 
 var _ = kubeadmission.WantsInternalKubeClientSet(&buildByStrategy{})
 var _ = oadmission.WantsOpenshiftInternalBuildClient(&buildByStrategy{})
 
 // NewBuildByStrategy returns an admission control for builds that checks
-// on policy based on the build strategy type
-func NewBuildByStrategy() admission.Interface {
-	return &buildByStrategy{
+// on policy based on the build strategy type. The config's AuthorizerType
+// selects which StrategyAuthorizer backend is used to make that decision;
+// it defaults to the original SubjectAccessReview-backed check.
+func NewBuildByStrategy(config *Config) (admission.Interface, error) {
+	if config == nil {
+		config = &Config{}
+	}
+	b := newBuildByStrategy()
+	b.config.Store(config)
+	switch config.AuthorizerType {
+	case "", AuthorizerTypeSAR:
+		// built once sarClient is injected in SetInternalKubeClientSet
+	case AuthorizerTypeWebhook:
+		b.authorizer = newWebhookStrategyAuthorizer(config.Webhook)
+	case AuthorizerTypeOPA:
+		b.authorizer = newOPAStrategyAuthorizer(config.OPA)
+	default:
+		return nil, fmt.Errorf("unrecognized BuildByStrategy authorizerType: %s", config.AuthorizerType)
+	}
+	return b, nil
+}
+
+// currentConfig returns the most recently loaded Config, reflecting any reload
+// picked up by startConfigWatcher.
+func (a *buildByStrategy) currentConfig() *Config {
+	return a.config.Load().(*Config)
+}
+
+// newBuildByStrategy constructs a buildByStrategy with its Handler and config
+// initialized to safe defaults; callers fill in the authorizer and, if needed, a
+// non-default config.
+func newBuildByStrategy() *buildByStrategy {
+	b := &buildByStrategy{
 		Handler: admission.NewHandler(admission.Create, admission.Update),
 	}
+	b.config.Store(&Config{})
+	return b
 }
 
 func (a *buildByStrategy) Admit(attr admission.Attributes) error {
@@ -74,7 +137,11 @@ func (a *buildByStrategy) Admit(attr admission.Attributes) error {
 	case *buildapi.Build:
 		return a.checkBuildAuthorization(obj, attr)
 	case *buildapi.BuildConfig:
-		return a.checkBuildConfigAuthorization(obj, attr)
+		if err := a.checkBuildConfigAuthorization(obj, attr); err != nil {
+			return err
+		}
+		recordTriggerAuthorizer(obj, attr)
+		return nil
 	case *buildapi.BuildRequest:
 		return a.checkBuildRequestAuthorization(obj, attr)
 	default:
@@ -84,6 +151,9 @@ func (a *buildByStrategy) Admit(attr admission.Attributes) error {
 
 func (a *buildByStrategy) SetInternalKubeClientSet(c internalclientset.Interface) {
 	a.sarClient = c.Authorization().SubjectAccessReviews()
+	if a.authorizer == nil {
+		a.authorizer = newSARStrategyAuthorizer(a.sarClient)
+	}
 }
 
 func (a *buildByStrategy) SetOpenshiftInternalBuildClient(c buildclient.Interface) {
@@ -97,6 +167,9 @@ func (a *buildByStrategy) ValidateInitialization() error {
 	if a.sarClient == nil {
 		return fmt.Errorf("BuildByStrategy needs an Openshift sarClient")
 	}
+	if a.authorizer == nil {
+		return fmt.Errorf("BuildByStrategy needs a StrategyAuthorizer")
+	}
 	return nil
 }
 
@@ -130,6 +203,9 @@ func (a *buildByStrategy) checkBuildAuthorization(build *buildapi.Build, attr ad
 	if err != nil {
 		return admission.NewForbidden(attr, err)
 	}
+	if err := a.checkImageAuthorization(strategy, attr); err != nil {
+		return err
+	}
 	subresource := ""
 	tokens := strings.SplitN(resource.Resource, "/", 2)
 	resourceType := tokens[0]
@@ -152,7 +228,23 @@ func (a *buildByStrategy) checkBuildAuthorization(build *buildapi.Build, attr ad
 	return a.checkAccess(strategy, sar, attr)
 }
 
+// checkBuildConfigAuthorization enforces the image policy and then the
+// strategy-create SAR check for a BuildConfig being saved directly. When a human
+// instantiating a build doesn't hold buildconfigs/instantiate rights,
+// checkBuildRequestAuthorization falls back to checkStrategyCreateAuthorization
+// instead, having already applied the image policy itself.
 func (a *buildByStrategy) checkBuildConfigAuthorization(buildConfig *buildapi.BuildConfig, attr admission.Attributes) error {
+	if err := a.checkImageAuthorization(buildConfig.Spec.Strategy, attr); err != nil {
+		return err
+	}
+	return a.checkStrategyCreateAuthorization(buildConfig, attr)
+}
+
+// checkStrategyCreateAuthorization performs the strategy-create SAR check on its
+// own, without the image policy check checkBuildConfigAuthorization also applies:
+// checkBuildRequestAuthorization needs to apply the image policy exactly once
+// regardless of which of its two resource-rights checks ends up granting access.
+func (a *buildByStrategy) checkStrategyCreateAuthorization(buildConfig *buildapi.BuildConfig, attr admission.Attributes) error {
 	strategy := buildConfig.Spec.Strategy
 	resource, err := resourceForStrategyType(strategy)
 	if err != nil {
@@ -181,6 +273,14 @@ func (a *buildByStrategy) checkBuildConfigAuthorization(buildConfig *buildapi.Bu
 }
 
 func (a *buildByStrategy) checkBuildRequestAuthorization(req *buildapi.BuildRequest, attr admission.Attributes) error {
+	// A BuildRequest's own namespace must match the request's namespace: nothing
+	// about the BuildRequest object itself is trusted, so without this a client
+	// could ask to instantiate a BuildConfig that lives in a different namespace
+	// than the one it's actually authorized against.
+	if len(req.Namespace) > 0 && req.Namespace != attr.GetNamespace() {
+		return admission.NewForbidden(attr, fmt.Errorf("build request namespace %q does not match request namespace %q", req.Namespace, attr.GetNamespace()))
+	}
+
 	gr := attr.GetResource().GroupResource()
 	switch gr {
 	case buildapi.Resource("builds"),
@@ -205,25 +305,86 @@ func (a *buildByStrategy) checkBuildRequestAuthorization(req *buildapi.BuildRequ
 		if err := buildscheme.InternalExternalScheme.Convert(buildConfig, internalBuildConfig, nil); err != nil {
 			return admission.NewForbidden(attr, err)
 		}
-		return a.checkBuildConfigAuthorization(internalBuildConfig, attr)
+
+		// A build-trigger controller (ImageChange/ConfigChange) instantiates
+		// builds using its own service account identity, not the identity of the
+		// human who configured the trigger. Re-checking "create" on the strategy
+		// resource as the controller would either require granting the
+		// controller blanket strategy-create rights (too broad) or deny every
+		// triggered build (too narrow). Instead only require "use", and rely on
+		// the rights recorded when the trigger was configured.
+		if isBuildTriggerControllerRequest(attr) {
+			return a.checkControllerInstantiateAuthorization(internalBuildConfig, attr)
+		}
+
+		// The image policy applies regardless of which of the two checks below
+		// ends up granting access, so it's applied once up front rather than
+		// inside each one.
+		if err := a.checkImageAuthorization(internalBuildConfig.Spec.Strategy, attr); err != nil {
+			return err
+		}
+		// buildconfigs/instantiate is a true alternative to strategy-create, not
+		// an additional gate on top of it: a subject who holds it may instantiate
+		// builds from this buildconfig without also holding create rights on the
+		// strategy resource. Only fall back to the strategy-create check when
+		// the subject doesn't hold it.
+		if err := a.checkInstantiateAuthorization(internalBuildConfig, attr); err == nil {
+			return nil
+		}
+		return a.checkStrategyCreateAuthorization(internalBuildConfig, attr)
 	default:
 		return admission.NewForbidden(attr, fmt.Errorf("Unknown resource type %s for BuildRequest", attr.GetResource()))
 	}
 }
 
+// checkAccess delegates the strategy-create decision to the configured
+// StrategyAuthorizer backend, then runs the result through resolveAccess.
 func (a *buildByStrategy) checkAccess(strategy buildapi.BuildStrategy, subjectAccessReview *authorization.SubjectAccessReview, attr admission.Attributes) error {
-	resp, err := a.sarClient.Create(subjectAccessReview)
-	if err != nil {
+	err := a.authorizer.Authorize(strategy, subjectAccessReview, attr)
+	return a.resolveAccess(strategy, err, subjectAccessReview, attr)
+}
+
+// resolveAccess turns the outcome of an authorization check - whether from a
+// StrategyAuthorizer backend or an equivalent direct SAR check such as the
+// image-policy escape hatch in checkImageAuthorization - into an admission
+// decision, honoring the strategy's configured DryRun mode and incrementing
+// deniedTotal the same way no matter which check produced the denial.
+func (a *buildByStrategy) resolveAccess(strategy buildapi.BuildStrategy, err error, subjectAccessReview *authorization.SubjectAccessReview, attr admission.Attributes) error {
+	if err == nil {
+		return nil
+	}
+
+	denied, ok := err.(*strategyDeniedError)
+	if !ok {
+		// The backend itself couldn't be consulted (unreachable, malformed
+		// response, ...). Fail closed regardless of the strategy's configured
+		// mode: a dry-run pilot must not mask an authorization outage.
 		return admission.NewForbidden(attr, err)
 	}
-	if !resp.Status.Allowed {
-		return notAllowed(strategy, attr)
+
+	strategyName := strategyTypeString(strategy)
+	mode := a.currentConfig().modeForStrategy(strategyName)
+	deniedTotal.WithLabelValues(strategyName, string(mode)).Inc()
+	if mode != ModeWarn {
+		return admission.NewForbidden(attr, denied)
+	}
+
+	rar := subjectAccessReview.Spec.ResourceAttributes
+	annotationErr := attr.AddAnnotation(
+		fmt.Sprintf("buildbystrategy.openshift.io/%s", strategyName),
+		fmt.Sprintf("Warning: would deny (verb=%s resource=%s%s): %v", rar.Verb, rar.Resource, subresourceSuffix(rar.Subresource), denied),
+	)
+	if annotationErr != nil {
+		glog.V(2).Infof("BuildByStrategy: unable to record dry-run annotation: %v", annotationErr)
 	}
 	return nil
 }
 
-func notAllowed(strategy buildapi.BuildStrategy, attr admission.Attributes) error {
-	return admission.NewForbidden(attr, fmt.Errorf("build strategy %s is not allowed", strategyTypeString(strategy)))
+func subresourceSuffix(subresource string) string {
+	if len(subresource) == 0 {
+		return ""
+	}
+	return "/" + subresource
 }
 
 func strategyTypeString(strategy buildapi.BuildStrategy) string {
@@ -0,0 +1,85 @@
+package strategyrestrictions
+
+import (
+	"fmt"
+	"testing"
+
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/kubernetes/pkg/apis/authorization"
+
+	buildapi "github.com/openshift/origin/pkg/build/apis/build"
+)
+
+// fixedResultAuthorizer is a StrategyAuthorizer stub that always returns err,
+// whatever it is - a *strategyDeniedError to simulate an affirmative denial, a
+// plain error to simulate the backend being unreachable, or nil to allow.
+type fixedResultAuthorizer struct {
+	err error
+}
+
+func (f fixedResultAuthorizer) Authorize(strategy buildapi.BuildStrategy, sar *authorization.SubjectAccessReview, attr admission.Attributes) error {
+	return f.err
+}
+
+func newTestAttr() admission.Attributes {
+	return admission.NewAttributesRecord(nil, nil,
+		buildapi.Kind("Build").WithVersion("v1"),
+		"myproject", "mybuild",
+		buildapi.Resource("builds").WithVersion("v1"),
+		"", admission.Create, nil, false, &user.DefaultInfo{Name: "alice"})
+}
+
+func dockerSAR() *authorization.SubjectAccessReview {
+	return &authorization.SubjectAccessReview{
+		Spec: authorization.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorization.ResourceAttributes{Verb: "create", Resource: "builds"},
+		},
+	}
+}
+
+func TestCheckAccessModeEnforce(t *testing.T) {
+	b := newBuildByStrategy()
+	b.authorizer = fixedResultAuthorizer{err: notAllowed(buildapi.BuildStrategy{DockerStrategy: &buildapi.DockerBuildStrategy{}})}
+	strategy := buildapi.BuildStrategy{DockerStrategy: &buildapi.DockerBuildStrategy{}}
+
+	if err := b.checkAccess(strategy, dockerSAR(), newTestAttr()); err == nil {
+		t.Fatal("expected the default ModeEnforce to deny the request")
+	}
+}
+
+func TestCheckAccessModeWarnAdmitsAndAnnotates(t *testing.T) {
+	b := newBuildByStrategy()
+	strategy := buildapi.BuildStrategy{DockerStrategy: &buildapi.DockerBuildStrategy{}}
+	b.authorizer = fixedResultAuthorizer{err: notAllowed(strategy)}
+	b.config.Store(&Config{DryRun: map[string]Mode{"Docker": ModeWarn}})
+
+	attr := newTestAttr()
+	if err := b.checkAccess(strategy, dockerSAR(), attr); err != nil {
+		t.Fatalf("expected ModeWarn to admit a denied request, got: %v", err)
+	}
+	if _, ok := attr.GetAnnotations()["buildbystrategy.openshift.io/Docker"]; !ok {
+		t.Errorf("expected a dry-run annotation recording the would-be denial, got %v", attr.GetAnnotations())
+	}
+}
+
+func TestCheckAccessFailsClosedOnBackendErrorEvenUnderModeWarn(t *testing.T) {
+	b := newBuildByStrategy()
+	strategy := buildapi.BuildStrategy{DockerStrategy: &buildapi.DockerBuildStrategy{}}
+	b.authorizer = fixedResultAuthorizer{err: fmt.Errorf("SAR endpoint unreachable")}
+	b.config.Store(&Config{DryRun: map[string]Mode{"Docker": ModeWarn}})
+
+	if err := b.checkAccess(strategy, dockerSAR(), newTestAttr()); err == nil {
+		t.Fatal("expected a backend error to fail closed regardless of ModeWarn")
+	}
+}
+
+func TestCheckAccessAllowed(t *testing.T) {
+	b := newBuildByStrategy()
+	b.authorizer = fixedResultAuthorizer{err: nil}
+	strategy := buildapi.BuildStrategy{DockerStrategy: &buildapi.DockerBuildStrategy{}}
+
+	if err := b.checkAccess(strategy, dockerSAR(), newTestAttr()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package strategyrestrictions
+
+import (
+	"fmt"
+
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/kubernetes/pkg/apis/authorization"
+
+	buildapi "github.com/openshift/origin/pkg/build/apis/build"
+)
+
+// StrategyAuthorizer decides whether the user described by a SubjectAccessReview
+// is allowed to create or update a build using the given strategy. It exists so
+// that buildByStrategy isn't hard-wired to SubjectAccessReview: operators can swap
+// in a webhook or policy engine without recompiling.
+type StrategyAuthorizer interface {
+	// Authorize returns nil if the request is allowed. If the backend was reached
+	// and affirmatively denied the request, the error is a *strategyDeniedError,
+	// which checkAccess may downgrade to a warning annotation under ModeWarn. Any
+	// other error means the backend itself couldn't be consulted (unreachable,
+	// malformed response, ...), and checkAccess always fails closed on it
+	// regardless of the strategy's configured mode.
+	Authorize(strategy buildapi.BuildStrategy, sar *authorization.SubjectAccessReview, attr admission.Attributes) error
+}
+
+// strategyDeniedError reports that a StrategyAuthorizer backend was consulted and
+// said no. It is deliberately not an admission error: checkAccess is responsible
+// for turning it into admission.NewForbidden (ModeEnforce) or a warning annotation
+// (ModeWarn), and needs to tell it apart from a backend it couldn't reach at all.
+type strategyDeniedError struct {
+	reason string
+}
+
+func (e *strategyDeniedError) Error() string { return e.reason }
+
+// notAllowed returns the denial reported when a backend is reached and denies the
+// strategy, without regard to which backend produced it.
+func notAllowed(strategy buildapi.BuildStrategy) error {
+	return &strategyDeniedError{reason: fmt.Sprintf("build strategy %s is not allowed", strategyTypeString(strategy))}
+}
+
+// sarStrategyAuthorizer is the original behavior: delegate the decision to the
+// API server's SubjectAccessReview endpoint. This remains the default backend.
+type sarStrategyAuthorizer struct {
+	sarClient authorizationSARCreator
+}
+
+// authorizationSARCreator is the subset of authorizationclient.SubjectAccessReviewInterface
+// that the SAR-backed authorizer needs, kept narrow so it's easy to fake in tests.
+type authorizationSARCreator interface {
+	Create(sar *authorization.SubjectAccessReview) (*authorization.SubjectAccessReview, error)
+}
+
+func newSARStrategyAuthorizer(sarClient authorizationSARCreator) StrategyAuthorizer {
+	return &sarStrategyAuthorizer{sarClient: sarClient}
+}
+
+func (a *sarStrategyAuthorizer) Authorize(strategy buildapi.BuildStrategy, sar *authorization.SubjectAccessReview, attr admission.Attributes) error {
+	resp, err := a.sarClient.Create(sar)
+	if err != nil {
+		// The SAR endpoint itself couldn't be consulted, as opposed to being
+		// consulted and denying the request; return it unwrapped so checkAccess -
+		// the only caller - is the single place that turns it into
+		// admission.NewForbidden, rather than wrapping it twice.
+		return err
+	}
+	if !resp.Status.Allowed {
+		return notAllowed(strategy)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package strategyrestrictions
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/kubernetes/pkg/apis/authorization"
+	kapi "k8s.io/kubernetes/pkg/apis/core"
+
+	buildapi "github.com/openshift/origin/pkg/build/apis/build"
+)
+
+func testAttr() admission.Attributes {
+	return admission.NewAttributesRecord(nil, nil,
+		buildapi.Kind("Build").WithVersion("v1"),
+		"myproject", "mybuild",
+		buildapi.Resource("builds").WithVersion("v1"),
+		"", admission.Create, nil, false, &user.DefaultInfo{Name: "alice"})
+}
+
+func customStrategyWithImage(image string) buildapi.BuildStrategy {
+	return buildapi.BuildStrategy{
+		CustomStrategy: &buildapi.CustomBuildStrategy{From: &kapi.ObjectReference{Name: image}},
+	}
+}
+
+// assertUnwrappedError fails the test unless err is a plain error carrying wantPrefix,
+// guarding against checkAccess's single admission.NewForbidden wrap being bypassed by
+// a second wrap inside the StrategyAuthorizer implementation itself.
+func assertUnwrappedError(t *testing.T, err error, wantPrefix string) {
+	t.Helper()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, ok := err.(*strategyDeniedError); ok {
+		t.Fatalf("expected an unreachable-backend error, got a *strategyDeniedError: %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), wantPrefix) {
+		t.Errorf("error = %q, want prefix %q (admission.NewForbidden must only be applied once, by checkAccess)", err.Error(), wantPrefix)
+	}
+}
+
+func TestSARStrategyAuthorizerDistinguishesDenialFromBackendError(t *testing.T) {
+	strategy := customStrategyWithImage("quay.io/org/image:latest")
+	sar := &authorization.SubjectAccessReview{}
+
+	t.Run("backend error is returned unwrapped", func(t *testing.T) {
+		fake := &fakeSARCreator{err: fmt.Errorf("etcd unavailable")}
+		authz := newSARStrategyAuthorizer(fake)
+		assertUnwrappedError(t, authz.Authorize(strategy, sar, testAttr()), "etcd unavailable")
+	})
+
+	t.Run("denial is a strategyDeniedError", func(t *testing.T) {
+		fake := &fakeSARCreator{allowed: false}
+		authz := newSARStrategyAuthorizer(fake)
+		err := authz.Authorize(strategy, sar, testAttr())
+		if _, ok := err.(*strategyDeniedError); !ok {
+			t.Fatalf("expected *strategyDeniedError, got %T: %v", err, err)
+		}
+	})
+
+	t.Run("allowed returns nil", func(t *testing.T) {
+		fake := &fakeSARCreator{allowed: true}
+		authz := newSARStrategyAuthorizer(fake)
+		if err := authz.Authorize(strategy, sar, testAttr()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestWebhookStrategyAuthorizer(t *testing.T) {
+	strategy := customStrategyWithImage("quay.io/org/image:latest")
+	sar := &authorization.SubjectAccessReview{
+		Spec: authorization.SubjectAccessReviewSpec{
+			User: "alice",
+			ResourceAttributes: &authorization.ResourceAttributes{
+				Verb: "create", Resource: "builds", Subresource: "custom",
+			},
+		},
+	}
+
+	t.Run("sends the strategy's builder image and resource attributes", func(t *testing.T) {
+		var got strategyReviewRequest
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+				t.Fatalf("decode request: %v", err)
+			}
+			json.NewEncoder(w).Encode(strategyReviewResponse{Allowed: true})
+		}))
+		defer server.Close()
+
+		authz := newWebhookStrategyAuthorizer(webhookStrategyAuthorizerConfig{URL: server.URL})
+		if err := authz.Authorize(strategy, sar, testAttr()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Image != "quay.io/org/image:latest" {
+			t.Errorf("review.Image = %q, want the strategy's builder image", got.Image)
+		}
+		if got.Verb != "create" || got.Resource != "builds" || got.Subresource != "custom" {
+			t.Errorf("review = %+v, want the SAR's verb/resource/subresource carried through", got)
+		}
+	})
+
+	t.Run("denial is a strategyDeniedError, not a forbidden wrap", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(strategyReviewResponse{Allowed: false, Reason: "no custom builds on Fridays"})
+		}))
+		defer server.Close()
+
+		authz := newWebhookStrategyAuthorizer(webhookStrategyAuthorizerConfig{URL: server.URL})
+		err := authz.Authorize(strategy, sar, testAttr())
+		denied, ok := err.(*strategyDeniedError)
+		if !ok {
+			t.Fatalf("expected *strategyDeniedError, got %T: %v", err, err)
+		}
+		if denied.Error() != "no custom builds on Fridays" {
+			t.Errorf("reason = %q, want the webhook's reason verbatim", denied.Error())
+		}
+	})
+
+	t.Run("unreachable webhook is returned unwrapped", func(t *testing.T) {
+		authz := newWebhookStrategyAuthorizer(webhookStrategyAuthorizerConfig{URL: "http://127.0.0.1:0"})
+		err := authz.Authorize(strategy, sar, testAttr())
+		assertUnwrappedError(t, err, "strategy authorization webhook")
+	})
+}
+
+func TestOPAStrategyAuthorizer(t *testing.T) {
+	strategy := customStrategyWithImage("quay.io/org/image:latest")
+	sar := &authorization.SubjectAccessReview{
+		Spec: authorization.SubjectAccessReviewSpec{
+			User: "alice",
+			ResourceAttributes: &authorization.ResourceAttributes{
+				Verb: "use", Resource: "builds",
+			},
+		},
+	}
+
+	t.Run("sends the strategy's builder image and resource attributes", func(t *testing.T) {
+		var got opaRequest
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+				t.Fatalf("decode request: %v", err)
+			}
+			json.NewEncoder(w).Encode(opaResult{Result: true})
+		}))
+		defer server.Close()
+
+		authz := newOPAStrategyAuthorizer(opaStrategyAuthorizerConfig{URL: server.URL})
+		if err := authz.Authorize(strategy, sar, testAttr()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Input.Image != "quay.io/org/image:latest" {
+			t.Errorf("input.Image = %q, want the strategy's builder image", got.Input.Image)
+		}
+		if got.Input.Verb != "use" || got.Input.Resource != "builds" {
+			t.Errorf("input = %+v, want the SAR's verb/resource carried through", got.Input)
+		}
+	})
+
+	t.Run("unreachable OPA endpoint is returned unwrapped", func(t *testing.T) {
+		authz := newOPAStrategyAuthorizer(opaStrategyAuthorizerConfig{URL: "http://127.0.0.1:0"})
+		err := authz.Authorize(strategy, sar, testAttr())
+		assertUnwrappedError(t, err, "OPA policy endpoint")
+	})
+}
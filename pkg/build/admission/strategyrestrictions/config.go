@@ -0,0 +1,114 @@
+package strategyrestrictions
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	kyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// AuthorizerType selects which StrategyAuthorizer backend buildByStrategy uses.
+type AuthorizerType string
+
+const (
+	// AuthorizerTypeSAR is the default: delegate to the cluster's
+	// SubjectAccessReview endpoint, as this plugin has always done.
+	AuthorizerTypeSAR AuthorizerType = "SubjectAccessReview"
+	// AuthorizerTypeWebhook delegates the decision to an external HTTP webhook.
+	AuthorizerTypeWebhook AuthorizerType = "Webhook"
+	// AuthorizerTypeOPA delegates the decision to an OPA/Rego policy document.
+	AuthorizerTypeOPA AuthorizerType = "OPA"
+)
+
+// Config is the admission plugin configuration for BuildByStrategy, decoded from the
+// io.Reader handed to the plugin's constructor in Register. It is optional: a nil or
+// empty reader keeps the original SubjectAccessReview-only behavior.
+type Config struct {
+	// AuthorizerType selects the StrategyAuthorizer backend. Defaults to
+	// AuthorizerTypeSAR when empty.
+	AuthorizerType AuthorizerType `json:"authorizerType"`
+
+	// Webhook configures the Webhook authorizer backend. Only read when
+	// AuthorizerType is AuthorizerTypeWebhook.
+	Webhook webhookStrategyAuthorizerConfig `json:"webhook"`
+
+	// OPA configures the OPA authorizer backend. Only read when AuthorizerType is
+	// AuthorizerTypeOPA.
+	OPA opaStrategyAuthorizerConfig `json:"opa"`
+
+	// ImagePolicy restricts which builder images the Custom and Docker strategies
+	// may reference, in addition to the strategy-type check above.
+	ImagePolicy ImagePolicyConfig `json:"imagePolicy"`
+
+	// DryRun maps a strategy type name (Docker, Source, Custom, JenkinsPipeline) to
+	// ModeWarn or ModeEnforce. Strategies absent from the map default to
+	// ModeEnforce. ModeWarn admits what would otherwise be a denied request,
+	// recording the would-be denial instead of blocking it, so operators can
+	// measure a policy's impact before enforcing it.
+	DryRun map[string]Mode `json:"dryRun"`
+}
+
+// Mode selects whether a strategy check blocks denied requests or only records them.
+type Mode string
+
+const (
+	// ModeEnforce blocks requests the strategy authorization denies. This is the
+	// default for any strategy not named in Config.DryRun.
+	ModeEnforce Mode = "enforce"
+	// ModeWarn admits requests the strategy authorization would have denied,
+	// recording an audit annotation and a denial-counter increment instead.
+	ModeWarn Mode = "warn"
+)
+
+// modeForStrategy returns the configured Mode for strategyName, defaulting to
+// ModeEnforce.
+func (c *Config) modeForStrategy(strategyName string) Mode {
+	if c == nil {
+		return ModeEnforce
+	}
+	if c.DryRun[strategyName] == ModeWarn {
+		return ModeWarn
+	}
+	return ModeEnforce
+}
+
+// ImagePolicyConfig holds the allowlist/denylist of builder images per strategy
+// type. An empty Allowed list means "no additional restriction"; Denied always
+// takes precedence over Allowed.
+type ImagePolicyConfig struct {
+	// Custom restricts the image referenced by CustomStrategy.From.
+	Custom StrategyImagePolicy `json:"custom"`
+	// Docker restricts the image referenced by DockerStrategy.From.
+	Docker StrategyImagePolicy `json:"docker"`
+}
+
+// StrategyImagePolicy is the allow/deny configuration for a single strategy type.
+// Image references and registry prefixes are matched as exact strings or, when a
+// registry entry ends in "/", as a prefix.
+type StrategyImagePolicy struct {
+	Allowed []string `json:"allowed"`
+	Denied  []string `json:"denied"`
+}
+
+// readConfig decodes the plugin configuration from the io.Reader passed to Register.
+// A nil reader (no config supplied) returns the zero Config, which builds the default
+// SAR-backed authorizer.
+func readConfig(reader io.Reader) (*Config, error) {
+	config := &Config{}
+	if reader == nil {
+		return config, nil
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return config, nil
+	}
+	if err := kyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096).Decode(config); err != nil {
+		return nil, fmt.Errorf("unable to decode BuildByStrategy admission config: %v", err)
+	}
+	return config, nil
+}
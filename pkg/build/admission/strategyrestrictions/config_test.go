@@ -0,0 +1,24 @@
+package strategyrestrictions
+
+import "testing"
+
+func TestModeForStrategy(t *testing.T) {
+	cases := []struct {
+		name   string
+		config *Config
+		want   Mode
+	}{
+		{"nil config defaults to enforce", nil, ModeEnforce},
+		{"empty DryRun defaults to enforce", &Config{}, ModeEnforce},
+		{"strategy not listed defaults to enforce", &Config{DryRun: map[string]Mode{"Source": ModeWarn}}, ModeEnforce},
+		{"strategy listed as warn", &Config{DryRun: map[string]Mode{"Docker": ModeWarn}}, ModeWarn},
+		{"unrecognized mode value defaults to enforce", &Config{DryRun: map[string]Mode{"Docker": Mode("bogus")}}, ModeEnforce},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.config.modeForStrategy("Docker"); got != c.want {
+				t.Errorf("modeForStrategy() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
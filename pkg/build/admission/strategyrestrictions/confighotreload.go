@@ -0,0 +1,46 @@
+package strategyrestrictions
+
+import (
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// configReloadInterval is how often startConfigWatcher checks the config file for
+// changes.
+const configReloadInterval = 30 * time.Second
+
+// startConfigWatcher polls path for modifications and, on change, re-decodes it and
+// swaps it into a's config store. This lets a cluster admin move a strategy from
+// ModeWarn to ModeEnforce, or adjust ImagePolicy, without restarting the API server.
+func startConfigWatcher(a *buildByStrategy, path string) {
+	var lastModTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		lastModTime = info.ModTime()
+	}
+
+	go wait.Until(func() {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().After(lastModTime) {
+			return
+		}
+		lastModTime = info.ModTime()
+
+		f, err := os.Open(path)
+		if err != nil {
+			glog.Warningf("BuildByStrategy: unable to re-read config %s: %v", path, err)
+			return
+		}
+		defer f.Close()
+
+		config, err := readConfig(f)
+		if err != nil {
+			glog.Warningf("BuildByStrategy: ignoring invalid config reload from %s: %v", path, err)
+			return
+		}
+		a.config.Store(config)
+		glog.V(2).Infof("BuildByStrategy: reloaded config from %s", path)
+	}, configReloadInterval, wait.NeverStop)
+}
@@ -0,0 +1,125 @@
+package strategyrestrictions
+
+import (
+	"fmt"
+	"io"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/admission"
+	kapi "k8s.io/kubernetes/pkg/apis/core"
+	"k8s.io/kubernetes/pkg/client/clientset_generated/internalclientset"
+	kubeadmission "k8s.io/kubernetes/pkg/kubeapiserver/admission"
+
+	buildclient "github.com/openshift/client-go/build/clientset/versioned"
+	buildapi "github.com/openshift/origin/pkg/build/apis/build"
+	"github.com/openshift/origin/pkg/build/buildscheme"
+	oadmission "github.com/openshift/origin/pkg/cmd/server/admission"
+)
+
+// buildPodLabel is set on every pod that runs a build; it names the Build the pod
+// belongs to. See pkg/build/controller/build, which sets it when creating build pods.
+const buildPodLabel = "openshift.io/build.name"
+
+// BuildByStrategyExecRestriction is the plugin name for buildPodExecRestriction. It is
+// registered alongside BuildByStrategy so that a user who isn't allowed to create a
+// Custom build also can't reach the equivalent privilege by exec'ing into one.
+const BuildByStrategyExecRestriction = "BuildByStrategyExecRestriction"
+
+func registerExecRestriction(plugins *admission.Plugins) {
+	plugins.Register(BuildByStrategyExecRestriction,
+		func(config io.Reader) (admission.Interface, error) {
+			// Build this plugin's own buildByStrategy from the same config.Reader
+			// it was handed, instead of the zero Config: otherwise the image
+			// allowlist, DryRun mode and pluggable authorizer backend in that
+			// config would silently not apply to exec/attach/portforward.
+			bbs, err := newConfiguredBuildByStrategy(config)
+			if err != nil {
+				return nil, err
+			}
+			return NewBuildByStrategyExecRestriction(bbs), nil
+		})
+}
+
+// buildPodExecRestriction re-runs BuildByStrategy's strategy authorization for
+// connect requests (exec, attach, portforward) against pods that belong to a build.
+// Without this, a user who was denied the ability to create a Custom build could
+// still exec into a running Custom build pod and reach the same privilege.
+type buildPodExecRestriction struct {
+	*admission.Handler
+	*buildByStrategy
+	podsGetter internalclientset.Interface
+}
+
+var _ = kubeadmission.WantsInternalKubeClientSet(&buildPodExecRestriction{})
+var _ = oadmission.WantsOpenshiftInternalBuildClient(&buildPodExecRestriction{})
+
+// NewBuildByStrategyExecRestriction returns an admission control plugin that applies
+// bbs's strategy checks - including its configured authorizer backend, image
+// allowlist and DryRun mode - to pods/exec, pods/attach and pods/portforward.
+func NewBuildByStrategyExecRestriction(bbs *buildByStrategy) admission.Interface {
+	return &buildPodExecRestriction{
+		Handler:         admission.NewHandler(admission.Connect),
+		buildByStrategy: bbs,
+	}
+}
+
+// podLookupError translates a failure to fetch the exec/attach/portforward target
+// pod into an admission decision. A not-found pod passes through so the downstream
+// handler can return its own not-found error; any other error means we can't tell
+// whether the pod belongs to a build, so it fails closed the same way
+// sccExecRestrictions does rather than letting the request through.
+func podLookupError(attr admission.Attributes, err error) error {
+	if kerrors.IsNotFound(err) {
+		return nil
+	}
+	return admission.NewForbidden(attr, fmt.Errorf("unable to resolve pod %s: %v", attr.GetName(), err))
+}
+
+func (a *buildPodExecRestriction) Admit(attr admission.Attributes) error {
+	if attr.GetResource().GroupResource() != kapi.Resource("pods") {
+		return nil
+	}
+	switch attr.GetSubresource() {
+	case "exec", "attach", "portforward":
+	default:
+		return nil
+	}
+
+	pod, err := a.podsGetter.Core().Pods(attr.GetNamespace()).Get(attr.GetName(), metav1.GetOptions{})
+	if err != nil {
+		return podLookupError(attr, err)
+	}
+
+	buildName, ok := pod.Labels[buildPodLabel]
+	if !ok {
+		return nil
+	}
+
+	build, err := a.buildClient.BuildV1().Builds(attr.GetNamespace()).Get(buildName, metav1.GetOptions{})
+	if err != nil {
+		return admission.NewForbidden(attr, fmt.Errorf("unable to resolve build %s for pod %s: %v", buildName, pod.Name, err))
+	}
+	internalBuild := &buildapi.Build{}
+	if err := buildscheme.InternalExternalScheme.Convert(build, internalBuild, nil); err != nil {
+		return admission.NewForbidden(attr, err)
+	}
+
+	return a.checkBuildAuthorization(internalBuild, attr)
+}
+
+func (a *buildPodExecRestriction) SetInternalKubeClientSet(c internalclientset.Interface) {
+	a.podsGetter = c
+	a.buildByStrategy.SetInternalKubeClientSet(c)
+}
+
+func (a *buildPodExecRestriction) SetOpenshiftInternalBuildClient(c buildclient.Interface) {
+	a.buildByStrategy.SetOpenshiftInternalBuildClient(c)
+}
+
+func (a *buildPodExecRestriction) ValidateInitialization() error {
+	if a.podsGetter == nil {
+		return fmt.Errorf("%s needs a Kubernetes client", BuildByStrategyExecRestriction)
+	}
+	return a.buildByStrategy.ValidateInitialization()
+}
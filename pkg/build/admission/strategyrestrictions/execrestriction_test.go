@@ -0,0 +1,48 @@
+package strategyrestrictions
+
+import (
+	"fmt"
+	"testing"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/user"
+
+	buildapi "github.com/openshift/origin/pkg/build/apis/build"
+)
+
+func TestNewBuildByStrategyExecRestrictionUsesGivenBuildByStrategy(t *testing.T) {
+	bbs := newBuildByStrategy()
+	bbs.config.Store(&Config{ImagePolicy: ImagePolicyConfig{Docker: StrategyImagePolicy{Denied: []string{"evil/image"}}}})
+
+	plugin := NewBuildByStrategyExecRestriction(bbs)
+	restriction, ok := plugin.(*buildPodExecRestriction)
+	if !ok {
+		t.Fatalf("expected *buildPodExecRestriction, got %T", plugin)
+	}
+	if restriction.buildByStrategy != bbs {
+		t.Error("NewBuildByStrategyExecRestriction must reuse the configured buildByStrategy it was given, not a fresh zero-config one")
+	}
+}
+
+func TestPodLookupError(t *testing.T) {
+	attr := admission.NewAttributesRecord(nil, nil,
+		schema.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		"myproject", "mypod",
+		schema.GroupVersionResource{Version: "v1", Resource: "pods"},
+		"exec", admission.Connect, nil, false, &user.DefaultInfo{Name: "alice"})
+
+	t.Run("not found passes through", func(t *testing.T) {
+		notFound := kerrors.NewNotFound(buildapi.Resource("pods"), "mypod")
+		if err := podLookupError(attr, notFound); err != nil {
+			t.Errorf("expected nil for a not-found pod, got %v", err)
+		}
+	})
+
+	t.Run("any other error fails closed", func(t *testing.T) {
+		if err := podLookupError(attr, fmt.Errorf("apiserver unavailable")); err == nil {
+			t.Error("expected a forbidden error for a non-not-found lookup failure, got nil")
+		}
+	})
+}
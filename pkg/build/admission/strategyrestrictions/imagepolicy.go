@@ -0,0 +1,118 @@
+package strategyrestrictions
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/kubernetes/pkg/apis/authorization"
+
+	"github.com/openshift/origin/pkg/authorization/util"
+	buildapi "github.com/openshift/origin/pkg/build/apis/build"
+)
+
+// anyImageSubresource is the SAR escape-hatch subresource: a cluster admin can grant
+// it per-user or per-service-account to exempt a subject from the image allowlist
+// without having to widen the allowlist itself.
+const anyImageSubresource = "anyimage"
+
+// strategyImageReference returns the builder image reference for strategies whose
+// image is attacker-influenced (Custom runs the image as arbitrary code; Docker can
+// supply a FROM image that overrides the generated Dockerfile's). Other strategies
+// return ok=false because their "image" is just the base S2I builder image and is
+// not considered privileged here.
+func strategyImageReference(strategy buildapi.BuildStrategy) (ref string, ok bool) {
+	switch {
+	case strategy.CustomStrategy != nil && strategy.CustomStrategy.From != nil:
+		return strategy.CustomStrategy.From.Name, true
+	case strategy.DockerStrategy != nil && strategy.DockerStrategy.From != nil:
+		return strategy.DockerStrategy.From.Name, true
+	}
+	return "", false
+}
+
+func imageMatches(ref string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "/") {
+			if strings.HasPrefix(ref, pattern) {
+				return true
+			}
+			continue
+		}
+		if ref == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// checkImageAuthorization enforces the configured allowlist/denylist of builder
+// images for the Custom and Docker strategies. A Denied match is always rejected.
+// An Allowed list, when non-empty, means the image must match it or the request is
+// rejected - in either case, unless the subject has the anyimage escape-hatch
+// permission. Like the strategy-create check, a rejection is routed through
+// resolveAccess so it honors the strategy's configured DryRun mode and counts
+// toward deniedTotal the same way a strategy-create denial would.
+func (a *buildByStrategy) checkImageAuthorization(strategy buildapi.BuildStrategy, attr admission.Attributes) error {
+	ref, ok := strategyImageReference(strategy)
+	if !ok {
+		return nil
+	}
+
+	policy := a.imagePolicyForStrategy(strategy)
+	switch {
+	case imageMatches(ref, policy.Denied):
+		return a.checkAnyImageEscapeHatch(strategy, ref, "image matches this cluster's denied image list", attr)
+	case len(policy.Allowed) > 0 && !imageMatches(ref, policy.Allowed):
+		return a.checkAnyImageEscapeHatch(strategy, ref, "image is not in the allowed list for this cluster", attr)
+	}
+	return nil
+}
+
+func (a *buildByStrategy) imagePolicyForStrategy(strategy buildapi.BuildStrategy) StrategyImagePolicy {
+	imagePolicy := a.currentConfig().ImagePolicy
+	switch {
+	case strategy.CustomStrategy != nil:
+		return imagePolicy.Custom
+	case strategy.DockerStrategy != nil:
+		return imagePolicy.Docker
+	}
+	return StrategyImagePolicy{}
+}
+
+// checkAnyImageEscapeHatch lets a cluster admin grant a specific user or service
+// account an exception to the image allowlist, the same way bootstrap policy grants
+// exceptions to the strategy-type SAR check via per-strategy resources. denyReason
+// describes why the image was rejected (denylist match vs allowlist miss) and is
+// only used if the escape hatch isn't granted. The result of the escape-hatch SAR
+// is routed through resolveAccess rather than hard-failing, so a denial here is
+// subject to the same DryRun mode as a strategy-create denial.
+func (a *buildByStrategy) checkAnyImageEscapeHatch(strategy buildapi.BuildStrategy, image, denyReason string, attr admission.Attributes) error {
+	resource, err := resourceForStrategyType(strategy)
+	if err != nil {
+		return admission.NewForbidden(attr, err)
+	}
+	resourceType := strings.SplitN(resource.Resource, "/", 2)[0]
+
+	sar := util.AddUserToSAR(attr.GetUserInfo(), &authorization.SubjectAccessReview{
+		Spec: authorization.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorization.ResourceAttributes{
+				Namespace:   attr.GetNamespace(),
+				Verb:        "create",
+				Group:       resource.Group,
+				Resource:    resourceType,
+				Subresource: anyImageSubresource,
+			},
+		},
+	})
+
+	resp, err := a.sarClient.Create(sar)
+	if err != nil {
+		return a.resolveAccess(strategy, err, sar, attr)
+	}
+	if resp.Status.Allowed {
+		return nil
+	}
+	deniedErr := &strategyDeniedError{reason: fmt.Sprintf("build strategy %s may not use image %q: %s", strategyTypeString(strategy), image, denyReason)}
+	return a.resolveAccess(strategy, deniedErr, sar, attr)
+}
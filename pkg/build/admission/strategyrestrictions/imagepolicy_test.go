@@ -0,0 +1,151 @@
+package strategyrestrictions
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/kubernetes/pkg/apis/authorization"
+	kapi "k8s.io/kubernetes/pkg/apis/core"
+
+	buildapi "github.com/openshift/origin/pkg/build/apis/build"
+)
+
+func TestImageMatches(t *testing.T) {
+	cases := []struct {
+		name     string
+		ref      string
+		patterns []string
+		want     bool
+	}{
+		{"empty patterns", "quay.io/org/image:latest", nil, false},
+		{"exact match", "quay.io/org/image:latest", []string{"quay.io/org/image:latest"}, true},
+		{"exact mismatch", "quay.io/org/image:latest", []string{"quay.io/org/other:latest"}, false},
+		{"registry prefix match", "quay.io/org/image:latest", []string{"quay.io/org/"}, true},
+		{"registry prefix mismatch", "docker.io/org/image:latest", []string{"quay.io/org/"}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := imageMatches(c.ref, c.patterns); got != c.want {
+				t.Errorf("imageMatches(%q, %v) = %v, want %v", c.ref, c.patterns, got, c.want)
+			}
+		})
+	}
+}
+
+func TestStrategyImageReference(t *testing.T) {
+	cases := []struct {
+		name     string
+		strategy buildapi.BuildStrategy
+		wantRef  string
+		wantOk   bool
+	}{
+		{"custom strategy", buildapi.BuildStrategy{CustomStrategy: &buildapi.CustomBuildStrategy{From: &kapi.ObjectReference{Name: "myimage:latest"}}}, "myimage:latest", true},
+		{"docker strategy", buildapi.BuildStrategy{DockerStrategy: &buildapi.DockerBuildStrategy{From: &kapi.ObjectReference{Name: "myimage:latest"}}}, "myimage:latest", true},
+		{"source strategy is not privileged", buildapi.BuildStrategy{SourceStrategy: &buildapi.SourceBuildStrategy{}}, "", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ref, ok := strategyImageReference(c.strategy)
+			if ref != c.wantRef || ok != c.wantOk {
+				t.Errorf("strategyImageReference() = (%q, %v), want (%q, %v)", ref, ok, c.wantRef, c.wantOk)
+			}
+		})
+	}
+}
+
+// fakeSARCreator implements authorizationclient.SubjectAccessReviewInterface,
+// recording the SAR it was asked to create.
+type fakeSARCreator struct {
+	allowed bool
+	err     error
+	gotSAR  *authorization.SubjectAccessReview
+}
+
+func (f *fakeSARCreator) Create(sar *authorization.SubjectAccessReview) (*authorization.SubjectAccessReview, error) {
+	f.gotSAR = sar
+	if f.err != nil {
+		return nil, f.err
+	}
+	sar.Status.Allowed = f.allowed
+	return sar, nil
+}
+
+func TestCheckAnyImageEscapeHatchSARShape(t *testing.T) {
+	fake := &fakeSARCreator{allowed: true}
+	b := newBuildByStrategy()
+	b.sarClient = fake
+
+	strategy := buildapi.BuildStrategy{CustomStrategy: &buildapi.CustomBuildStrategy{}}
+	attr := admission.NewAttributesRecord(nil, nil,
+		buildapi.Kind("Build").WithVersion("v1"),
+		"myproject", "mybuild",
+		buildapi.Resource("builds").WithVersion("v1"),
+		"", admission.Create, nil, false, &user.DefaultInfo{Name: "alice"})
+
+	if err := b.checkAnyImageEscapeHatch(strategy, "quay.io/evil/image:latest", "image is not in the allowed list for this cluster", attr); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if fake.gotSAR == nil {
+		t.Fatal("expected a SubjectAccessReview to be created")
+	}
+	rar := fake.gotSAR.Spec.ResourceAttributes
+	if rar.Subresource != anyImageSubresource {
+		t.Errorf("Subresource = %q, want %q", rar.Subresource, anyImageSubresource)
+	}
+	if rar.Resource == "" || strings.Contains(rar.Resource, "/") {
+		t.Errorf("Resource = %q, must be the bare resource type with no embedded subresource now that Subresource is set separately", rar.Resource)
+	}
+}
+
+func newImagePolicyAttr() admission.Attributes {
+	return admission.NewAttributesRecord(nil, nil,
+		buildapi.Kind("Build").WithVersion("v1"),
+		"myproject", "mybuild",
+		buildapi.Resource("builds").WithVersion("v1"),
+		"", admission.Create, nil, false, &user.DefaultInfo{Name: "alice"})
+}
+
+func TestCheckImageAuthorizationDenyReasons(t *testing.T) {
+	cases := []struct {
+		name       string
+		policy     StrategyImagePolicy
+		wantReason string
+	}{
+		{"denylist match", StrategyImagePolicy{Denied: []string{"quay.io/evil/image:latest"}}, "image matches this cluster's denied image list"},
+		{"allowlist miss", StrategyImagePolicy{Allowed: []string{"quay.io/org/"}}, "image is not in the allowed list for this cluster"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b := newBuildByStrategy()
+			b.sarClient = &fakeSARCreator{allowed: false}
+			b.config.Store(&Config{ImagePolicy: ImagePolicyConfig{Custom: c.policy}})
+
+			strategy := buildapi.BuildStrategy{CustomStrategy: &buildapi.CustomBuildStrategy{From: &kapi.ObjectReference{Name: "quay.io/evil/image:latest"}}}
+			err := b.checkImageAuthorization(strategy, newImagePolicyAttr())
+			if err == nil || !strings.Contains(err.Error(), c.wantReason) {
+				t.Fatalf("checkImageAuthorization() error = %v, want it to mention %q", err, c.wantReason)
+			}
+		})
+	}
+}
+
+func TestCheckImageAuthorizationRespectsDryRunMode(t *testing.T) {
+	b := newBuildByStrategy()
+	b.sarClient = &fakeSARCreator{allowed: false}
+	b.config.Store(&Config{
+		ImagePolicy: ImagePolicyConfig{Custom: StrategyImagePolicy{Allowed: []string{"quay.io/org/"}}},
+		DryRun:      map[string]Mode{"Custom": ModeWarn},
+	})
+
+	strategy := buildapi.BuildStrategy{CustomStrategy: &buildapi.CustomBuildStrategy{From: &kapi.ObjectReference{Name: "quay.io/evil/image:latest"}}}
+	attr := newImagePolicyAttr()
+	if err := b.checkImageAuthorization(strategy, attr); err != nil {
+		t.Fatalf("expected ModeWarn to admit an image-policy denial, got: %v", err)
+	}
+	if _, ok := attr.GetAnnotations()["buildbystrategy.openshift.io/Custom"]; !ok {
+		t.Errorf("expected a dry-run annotation recording the would-be image-policy denial, got %v", attr.GetAnnotations())
+	}
+}
@@ -0,0 +1,162 @@
+package strategyrestrictions
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/kubernetes/pkg/apis/authorization"
+	"k8s.io/kubernetes/pkg/serviceaccount"
+
+	"github.com/openshift/origin/pkg/authorization/util"
+	buildapi "github.com/openshift/origin/pkg/build/apis/build"
+	"github.com/openshift/origin/pkg/cmd/server/bootstrappolicy"
+)
+
+// triggerAuthorizedByAnnotation records which user most recently saved a
+// BuildConfig while passing its strategy-create check, written by
+// recordTriggerAuthorizer. When the trigger controller later instantiates a build
+// on the user's behalf, checkControllerInstantiateAuthorization re-runs that same
+// strategy-create check impersonating this recorded user, instead of checking the
+// controller's own (unrelated) identity against the strategy resource.
+const triggerAuthorizedByAnnotation = "build.openshift.io/trigger-authorized-by"
+
+// buildTriggerControllerUser is the identity the ImageChange/ConfigChange trigger
+// controllers use when they instantiate a build from a BuildConfig, mirroring how
+// OwnerReferencesPermissionEnforcement distinguishes garbage-collector-initiated
+// updates from ones made by an ordinary human or client.
+var buildTriggerControllerUser = serviceaccount.MakeUsername(bootstrappolicy.DefaultOpenShiftInfraNamespace, bootstrappolicy.InfraBuildControllerServiceAccountName)
+
+func isBuildTriggerControllerRequest(attr admission.Attributes) bool {
+	return attr.GetUserInfo().GetName() == buildTriggerControllerUser
+}
+
+// hasControllerTrigger reports whether buildConfig has an ImageChange or
+// ConfigChange trigger, i.e. one the trigger controllers - not a human request -
+// can fire on their own initiative.
+func hasControllerTrigger(buildConfig *buildapi.BuildConfig) bool {
+	for _, trigger := range buildConfig.Spec.Triggers {
+		switch trigger.Type {
+		case buildapi.ImageChangeBuildTriggerType, buildapi.ConfigChangeBuildTriggerType:
+			return true
+		}
+	}
+	return false
+}
+
+// recordTriggerAuthorizer stamps buildConfig with the identity of the user who just
+// passed checkBuildConfigAuthorization's strategy-create check, provided the
+// buildconfig has a trigger the controllers can fire unprompted. It overwrites any
+// previous value, so the annotation always names whoever most recently saved the
+// buildconfig with strategy-create rights; checkControllerInstantiateAuthorization
+// re-verifies that this recorded identity still holds those rights before letting a
+// trigger-fired build through.
+//
+// This only runs on the admission path for BuildConfig objects themselves
+// (Admit's *buildapi.BuildConfig case): checkStrategyCreateAuthorization is also
+// called while admitting a human-initiated BuildRequest, but mutating the
+// BuildConfig fetched there wouldn't persist, so that path must not call this.
+func recordTriggerAuthorizer(buildConfig *buildapi.BuildConfig, attr admission.Attributes) {
+	if !hasControllerTrigger(buildConfig) {
+		return
+	}
+	if buildConfig.Annotations == nil {
+		buildConfig.Annotations = map[string]string{}
+	}
+	buildConfig.Annotations[triggerAuthorizedByAnnotation] = attr.GetUserInfo().GetName()
+}
+
+// checkInstantiateAuthorization performs a distinct buildconfigs/instantiate SAR
+// check that checkBuildRequestAuthorization treats as a true alternative to the
+// strategy-create check performed by checkStrategyCreateAuthorization, not an
+// additional gate on top of it: holding it is enough to instantiate a build from
+// this buildconfig, letting an admin grant that right without also granting
+// blanket create rights on the strategy resource. checkBuildRequestAuthorization
+// only falls back to the strategy-create check when this one is denied.
+func (a *buildByStrategy) checkInstantiateAuthorization(buildConfig *buildapi.BuildConfig, attr admission.Attributes) error {
+	resource := buildapi.Resource("buildconfigs")
+	sar := util.AddUserToSAR(attr.GetUserInfo(), &authorization.SubjectAccessReview{
+		Spec: authorization.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorization.ResourceAttributes{
+				Namespace:   attr.GetNamespace(),
+				Verb:        "create",
+				Group:       resource.Group,
+				Resource:    resource.Resource,
+				Subresource: "instantiate",
+				Name:        buildConfig.Name,
+			},
+		},
+	})
+	resp, err := a.sarClient.Create(sar)
+	if err != nil {
+		return admission.NewForbidden(attr, err)
+	}
+	if !resp.Status.Allowed {
+		return admission.NewForbidden(attr, fmt.Errorf("user %s may not instantiate builds from buildconfig %s/%s", attr.GetUserInfo().GetName(), buildConfig.Namespace, buildConfig.Name))
+	}
+	return nil
+}
+
+// checkControllerInstantiateAuthorization is the trigger-controller counterpart of
+// checkBuildConfigAuthorization: it requires only "use" on the strategy resource for
+// the controller's own identity, and additionally re-runs the strategy-create check
+// that checkBuildConfigAuthorization performed when the trigger was last saved,
+// impersonating the user recorded in triggerAuthorizedByAnnotation. That annotation
+// is only ever written by recordTriggerAuthorizer after a real strategy-create SAR
+// succeeded, so this confirms the recorded identity's rights haven't since been
+// revoked - not merely that some string is present.
+func (a *buildByStrategy) checkControllerInstantiateAuthorization(buildConfig *buildapi.BuildConfig, attr admission.Attributes) error {
+	strategy := buildConfig.Spec.Strategy
+	resource, err := resourceForStrategyType(strategy)
+	if err != nil {
+		return admission.NewForbidden(attr, err)
+	}
+	tokens := strings.SplitN(resource.Resource, "/", 2)
+	resourceType := tokens[0]
+	subresource := ""
+	if len(tokens) == 2 {
+		subresource = tokens[1]
+	}
+
+	sar := util.AddUserToSAR(attr.GetUserInfo(), &authorization.SubjectAccessReview{
+		Spec: authorization.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorization.ResourceAttributes{
+				Namespace:   attr.GetNamespace(),
+				Verb:        "use",
+				Group:       resource.Group,
+				Resource:    resourceType,
+				Subresource: subresource,
+			},
+		},
+	})
+	if err := a.checkAccess(strategy, sar, attr); err != nil {
+		return err
+	}
+
+	authorizedBy := buildConfig.Annotations[triggerAuthorizedByAnnotation]
+	if len(authorizedBy) == 0 {
+		return admission.NewForbidden(attr, fmt.Errorf(
+			"buildconfig %s/%s has a trigger but is missing the %q annotation recording who configured it; re-save the buildconfig's trigger to re-authorize it",
+			buildConfig.Namespace, buildConfig.Name, triggerAuthorizedByAnnotation))
+	}
+
+	triggerSAR := util.AddUserToSAR(&user.DefaultInfo{Name: authorizedBy}, &authorization.SubjectAccessReview{
+		Spec: authorization.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorization.ResourceAttributes{
+				Namespace:   attr.GetNamespace(),
+				Verb:        "create",
+				Group:       resource.Group,
+				Resource:    resourceType,
+				Subresource: subresource,
+				Name:        buildConfig.Name,
+			},
+		},
+	})
+	if err := a.checkAccess(strategy, triggerSAR, attr); err != nil {
+		return admission.NewForbidden(attr, fmt.Errorf(
+			"buildconfig %s/%s trigger was authorized by %s, who may no longer create %s builds: %v",
+			buildConfig.Namespace, buildConfig.Name, authorizedBy, strategyTypeString(strategy), err))
+	}
+	return nil
+}
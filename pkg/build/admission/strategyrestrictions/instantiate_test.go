@@ -0,0 +1,152 @@
+package strategyrestrictions
+
+import (
+	"testing"
+
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/kubernetes/pkg/apis/authorization"
+
+	buildapi "github.com/openshift/origin/pkg/build/apis/build"
+)
+
+// fakeControllerAuthorizer is a StrategyAuthorizer that allows only the SAR users
+// named in allowed, so tests can simulate a recorded trigger-authorizer whose
+// rights have since been revoked.
+type fakeControllerAuthorizer struct {
+	allowed map[string]bool
+}
+
+func (f *fakeControllerAuthorizer) Authorize(strategy buildapi.BuildStrategy, sar *authorization.SubjectAccessReview, attr admission.Attributes) error {
+	if f.allowed[sar.Spec.User] {
+		return nil
+	}
+	return notAllowed(strategy)
+}
+
+func newTestBuildConfig(annotations map[string]string, triggerTypes ...buildapi.BuildTriggerType) *buildapi.BuildConfig {
+	bc := &buildapi.BuildConfig{
+		Spec: buildapi.BuildConfigSpec{
+			CommonSpec: buildapi.CommonSpec{
+				Strategy: buildapi.BuildStrategy{
+					SourceStrategy: &buildapi.SourceBuildStrategy{},
+				},
+			},
+		},
+	}
+	bc.Namespace = "myproject"
+	bc.Name = "mybuild"
+	bc.Annotations = annotations
+	for _, t := range triggerTypes {
+		bc.Spec.Triggers = append(bc.Spec.Triggers, buildapi.BuildTriggerPolicy{Type: t})
+	}
+	return bc
+}
+
+func attrsFor(userName string) admission.Attributes {
+	return admission.NewAttributesRecord(nil, nil,
+		buildapi.Kind("BuildConfig").WithVersion("v1"),
+		"myproject", "mybuild",
+		buildapi.Resource("buildconfigs").WithVersion("v1"),
+		"", admission.Update, nil, false, &user.DefaultInfo{Name: userName})
+}
+
+func TestHasControllerTrigger(t *testing.T) {
+	cases := []struct {
+		name     string
+		triggers []buildapi.BuildTriggerType
+		want     bool
+	}{
+		{"no triggers", nil, false},
+		{"generic webhook only", []buildapi.BuildTriggerType{buildapi.GenericWebHookBuildTriggerType}, false},
+		{"image change", []buildapi.BuildTriggerType{buildapi.ImageChangeBuildTriggerType}, true},
+		{"config change", []buildapi.BuildTriggerType{buildapi.ConfigChangeBuildTriggerType}, true},
+		{"mixed", []buildapi.BuildTriggerType{buildapi.GenericWebHookBuildTriggerType, buildapi.ImageChangeBuildTriggerType}, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			bc := newTestBuildConfig(nil, c.triggers...)
+			if got := hasControllerTrigger(bc); got != c.want {
+				t.Errorf("hasControllerTrigger() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRecordTriggerAuthorizer(t *testing.T) {
+	t.Run("no controller trigger leaves annotations untouched", func(t *testing.T) {
+		bc := newTestBuildConfig(nil)
+		recordTriggerAuthorizer(bc, attrsFor("alice"))
+		if _, ok := bc.Annotations[triggerAuthorizedByAnnotation]; ok {
+			t.Errorf("expected no %s annotation on a buildconfig with no controller trigger", triggerAuthorizedByAnnotation)
+		}
+	})
+
+	t.Run("controller trigger records the requesting user", func(t *testing.T) {
+		bc := newTestBuildConfig(nil, buildapi.ImageChangeBuildTriggerType)
+		recordTriggerAuthorizer(bc, attrsFor("alice"))
+		if got := bc.Annotations[triggerAuthorizedByAnnotation]; got != "alice" {
+			t.Errorf("annotation = %q, want %q", got, "alice")
+		}
+	})
+
+	t.Run("re-saving overwrites a stale recorded user", func(t *testing.T) {
+		bc := newTestBuildConfig(map[string]string{triggerAuthorizedByAnnotation: "alice"}, buildapi.ImageChangeBuildTriggerType)
+		recordTriggerAuthorizer(bc, attrsFor("bob"))
+		if got := bc.Annotations[triggerAuthorizedByAnnotation]; got != "bob" {
+			t.Errorf("annotation = %q, want %q", got, "bob")
+		}
+	})
+}
+
+func TestCheckInstantiateAuthorization(t *testing.T) {
+	bc := newTestBuildConfig(nil)
+
+	t.Run("allowed", func(t *testing.T) {
+		b := newBuildByStrategy()
+		b.sarClient = &fakeSARCreator{allowed: true}
+		if err := b.checkInstantiateAuthorization(bc, attrsFor("alice")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		b := newBuildByStrategy()
+		b.sarClient = &fakeSARCreator{allowed: false}
+		if err := b.checkInstantiateAuthorization(bc, attrsFor("alice")); err == nil {
+			t.Fatal("expected an error when the subject lacks buildconfigs/instantiate rights")
+		}
+	})
+}
+
+func TestCheckControllerInstantiateAuthorization(t *testing.T) {
+	newSubject := func(authorized map[string]bool) *buildByStrategy {
+		b := newBuildByStrategy()
+		b.authorizer = &fakeControllerAuthorizer{allowed: authorized}
+		return b
+	}
+
+	t.Run("missing annotation is forbidden even though the controller may use the strategy", func(t *testing.T) {
+		bc := newTestBuildConfig(nil, buildapi.ImageChangeBuildTriggerType)
+		b := newSubject(map[string]bool{buildTriggerControllerUser: true})
+		if err := b.checkControllerInstantiateAuthorization(bc, attrsFor(buildTriggerControllerUser)); err == nil {
+			t.Fatal("expected an error for a buildconfig missing the trigger-authorized-by annotation")
+		}
+	})
+
+	t.Run("a non-empty annotation naming a user without strategy rights is forbidden", func(t *testing.T) {
+		bc := newTestBuildConfig(map[string]string{triggerAuthorizedByAnnotation: "mallory"}, buildapi.ImageChangeBuildTriggerType)
+		b := newSubject(map[string]bool{buildTriggerControllerUser: true})
+		if err := b.checkControllerInstantiateAuthorization(bc, attrsFor(buildTriggerControllerUser)); err == nil {
+			t.Fatal("expected an error when the recorded trigger authorizer no longer has strategy-create rights")
+		}
+	})
+
+	t.Run("a recorded user who still has strategy rights is allowed", func(t *testing.T) {
+		bc := newTestBuildConfig(map[string]string{triggerAuthorizedByAnnotation: "alice"}, buildapi.ImageChangeBuildTriggerType)
+		b := newSubject(map[string]bool{buildTriggerControllerUser: true, "alice": true})
+		if err := b.checkControllerInstantiateAuthorization(bc, attrsFor(buildTriggerControllerUser)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,18 @@
+package strategyrestrictions
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// deniedTotal counts every build strategy request that BuildByStrategy denied, or
+// would have denied under ModeWarn, broken down by strategy and mode so operators
+// can quantify the impact of flipping a strategy from warn to enforce.
+var deniedTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "buildbystrategy_denied_total",
+		Help: "Number of build strategy requests denied (or that would have been denied in warn mode) by BuildByStrategy.",
+	},
+	[]string{"strategy", "mode"},
+)
+
+func init() {
+	prometheus.MustRegister(deniedTotal)
+}
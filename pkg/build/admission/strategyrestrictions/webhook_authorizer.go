@@ -0,0 +1,114 @@
+package strategyrestrictions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/kubernetes/pkg/apis/authorization"
+
+	buildapi "github.com/openshift/origin/pkg/build/apis/build"
+)
+
+// webhookStrategyAuthorizerConfig configures a webhookStrategyAuthorizer, analogous to
+// a Kubernetes admission webhook: the plugin POSTs a review object and expects an
+// allow/deny decision back.
+type webhookStrategyAuthorizerConfig struct {
+	// URL is the endpoint that receives the webhook review POST.
+	URL string `json:"url"`
+	// Timeout bounds how long to wait for the webhook to respond before failing closed.
+	Timeout time.Duration `json:"timeout"`
+}
+
+// strategyReviewRequest is the body POSTed to the webhook. It mirrors the fields an
+// admission webhook would need to reach the same decision the SAR-backed authorizer
+// would have made.
+type strategyReviewRequest struct {
+	Strategy  string   `json:"strategy"`
+	User      string   `json:"user"`
+	Groups    []string `json:"groups"`
+	Namespace string   `json:"namespace"`
+	// Resource, Subresource and Verb mirror the SubjectAccessReview the SAR-backed
+	// authorizer would have issued for this same call (e.g. "create" on
+	// "builds/docker", or "use" for a trigger-controller instantiate), so a policy
+	// can draw the same create-vs-use, per-resource distinctions the SAR backend
+	// does instead of collapsing every caller to an identical review.
+	Resource    string `json:"resource"`
+	Subresource string `json:"subresource,omitempty"`
+	Verb        string `json:"verb"`
+	// Image is the builder image referenced by the Custom or Docker strategy, if
+	// any, so a policy can be written in terms of it (e.g. "allow Custom builds
+	// only with specific base images").
+	Image string `json:"image,omitempty"`
+}
+
+// strategyReviewResponse is the expected JSON body of the webhook's reply.
+type strategyReviewResponse struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason"`
+}
+
+type webhookStrategyAuthorizer struct {
+	config     webhookStrategyAuthorizerConfig
+	httpClient *http.Client
+}
+
+func newWebhookStrategyAuthorizer(config webhookStrategyAuthorizerConfig) StrategyAuthorizer {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &webhookStrategyAuthorizer{
+		config:     config,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+func (a *webhookStrategyAuthorizer) Authorize(strategy buildapi.BuildStrategy, sar *authorization.SubjectAccessReview, attr admission.Attributes) error {
+	review := strategyReviewRequest{
+		Strategy:  strategyTypeString(strategy),
+		User:      sar.Spec.User,
+		Groups:    sar.Spec.Groups,
+		Namespace: attr.GetNamespace(),
+	}
+	if rar := sar.Spec.ResourceAttributes; rar != nil {
+		review.Resource = rar.Resource
+		review.Subresource = rar.Subresource
+		review.Verb = rar.Verb
+	}
+	if image, ok := strategyImageReference(strategy); ok {
+		review.Image = image
+	}
+
+	body, err := json.Marshal(review)
+	if err != nil {
+		return fmt.Errorf("unable to build strategy webhook request: %v", err)
+	}
+
+	// Errors below mean the webhook itself couldn't be consulted, as opposed to
+	// being consulted and denying the request; they are deliberately returned
+	// unwrapped so checkAccess - the only caller - is the single place that turns
+	// them into admission.NewForbidden, rather than wrapping them twice.
+	resp, err := a.httpClient.Post(a.config.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("strategy authorization webhook %s is unreachable: %v", a.config.URL, err)
+	}
+	defer resp.Body.Close()
+
+	var decision strategyReviewResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return fmt.Errorf("strategy authorization webhook %s returned an invalid response: %v", a.config.URL, err)
+	}
+
+	if !decision.Allowed {
+		reason := decision.Reason
+		if len(reason) == 0 {
+			reason = fmt.Sprintf("build strategy %s is not allowed", strategyTypeString(strategy))
+		}
+		return &strategyDeniedError{reason: reason}
+	}
+	return nil
+}